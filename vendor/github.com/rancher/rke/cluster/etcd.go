@@ -2,6 +2,7 @@ package cluster
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"regexp"
@@ -11,6 +12,7 @@ import (
 	"github.com/rancher/rke/log"
 	"github.com/rancher/rke/pki"
 	"github.com/rancher/rke/services"
+	"github.com/rancher/rke/services/snapshotserver"
 	"github.com/rancher/rke/util"
 	"golang.org/x/sync/errgroup"
 )
@@ -20,13 +22,50 @@ const (
 )
 
 func (c *Cluster) SnapshotEtcd(ctx context.Context, snapshotName string) error {
-	backupImage := c.getBackupImage()
-	for _, host := range c.EtcdHosts {
-		if err := services.RunEtcdSnapshotSave(ctx, host, c.PrivateRegistriesMap, backupImage, snapshotName, true, c.Services.Etcd); err != nil {
+	if client, err := c.snapshotServerClient(ctx); err != nil {
+		return err
+	} else if client != nil {
+		result, err := client.Save(snapshotName)
+		if err != nil {
 			return err
 		}
+		return snapshotResultsToError(result.Hosts)
 	}
-	return nil
+
+	// ContinueOnError so a single unreachable host doesn't stop the snapshot
+	// from being taken (and counted as a failure) on every other host.
+	_, err := c.SnapshotEtcdWithOptions(ctx, snapshotName, SnapshotOptions{ContinueOnError: true})
+	return err
+}
+
+// resolveS3SnapshotEnv resolves the configured S3CredentialSource, if any,
+// into the ephemeral env vars that should be passed to the rke-tools
+// container for this snapshot operation. Credentials are never written to
+// disk on the etcd node; they only live for the lifetime of the container.
+func (c *Cluster) resolveS3SnapshotEnv(ctx context.Context) ([]string, error) {
+	s3Config := c.Services.Etcd.BackupConfig
+	if s3Config == nil || s3Config.S3BackupConfig == nil {
+		return nil, nil
+	}
+	credSource, err := GetS3CredentialSource(s3Config.S3BackupConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve S3 credential source: %v", err)
+	}
+	creds, err := credSource.Resolve(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve S3 credentials: %v", err)
+	}
+	var env []string
+	if creds.AccessKey != "" {
+		env = append(env, fmt.Sprintf("AWS_ACCESS_KEY_ID=%s", creds.AccessKey))
+	}
+	if creds.SecretKey != "" {
+		env = append(env, fmt.Sprintf("AWS_SECRET_ACCESS_KEY=%s", creds.SecretKey))
+	}
+	if s3Config.S3BackupConfig.S3Proxy != "" {
+		env = append(env, fmt.Sprintf("HTTPS_PROXY=%s", s3Config.S3BackupConfig.S3Proxy))
+	}
+	return env, nil
 }
 
 func (c *Cluster) DeployRestoreCerts(ctx context.Context, clusterCerts map[string]pki.CertificatePKI) error {
@@ -102,72 +141,110 @@ func (c *Cluster) PrepareBackup(ctx context.Context, snapshotPath string) error
 		backupReady = true
 	}
 
-	// s3 backup case
-	if c.Services.Etcd.BackupConfig != nil &&
-		c.Services.Etcd.BackupConfig.S3BackupConfig != nil && !IsLocalSnapshot(snapshotPath) {
-		for _, host := range c.EtcdHosts {
-			if err := services.DownloadEtcdSnapshotFromS3(ctx, host, c.PrivateRegistriesMap, backupImage, snapshotPath, c.Services.Etcd); err != nil {
-				return err
+	// remote backend case: S3, GCS, Azure or restic, dispatched on backend.Kind()
+	if c.Services.Etcd.BackupConfig != nil && !IsLocalSnapshot(snapshotPath) {
+		backend, err := c.resolveSnapshotBackend(snapshotPath)
+		if err != nil {
+			if c.Services.Etcd.BackupConfig.S3BackupConfig == nil && c.Services.Etcd.BackupConfig.Backend == nil {
+				return fmt.Errorf(BackupPrepareError)
 			}
+			return err
+		}
+		if _, err := c.fanOutHosts(ctx, SnapshotOptions{}, func(ctx context.Context, host *hosts.Host) error {
+			return backend.Fetch(ctx, host, snapshotPath)
+		}); err != nil {
+			return err
 		}
 		backupReady = true
 	}
 	if !backupReady {
-		if !IsLocalSnapshot(snapshotPath) &&
-			c.Services.Etcd.BackupConfig != nil &&
-			c.Services.Etcd.BackupConfig.S3BackupConfig == nil { // s3 backup with no s3 configuration!
-			return fmt.Errorf(BackupPrepareError)
-		}
 		return fmt.Errorf("failed to prepare backup for restore")
 	}
+	// Refuse to restore if the snapshot's sha256/signature manifest doesn't
+	// check out. A missing manifest is expected for snapshots taken before
+	// manifests existed, so it's logged and tolerated rather than treated as
+	// a restore blocker; any other error means a manifest exists and didn't
+	// validate, which does block the restore.
+	if _, err := c.fetchAndValidateManifest(ctx, c.EtcdHosts[0], snapshotPath); err != nil {
+		if !stderrors.Is(err, services.ErrSnapshotManifestNotFound) {
+			return err
+		}
+		log.Warnf(ctx, "[etcd] snapshot [%s] has no manifest sidecar, skipping integrity validation: %v", snapshotPath, err)
+	}
 	// this applies to all cases!
-	if isEqual := c.etcdSnapshotChecksum(ctx, snapshotPath); !isEqual {
+	if isEqual := c.etcdSnapshotChecksumWithOptions(ctx, snapshotPath, SnapshotOptions{}); !isEqual {
 		return fmt.Errorf("etcd snapshots are not consistent")
 	}
 	return nil
 }
 
 func (c *Cluster) RestoreEtcdSnapshot(ctx context.Context, snapshotPath string) error {
-	// Start restore process on all etcd hosts
-	initCluster := services.GetEtcdInitialCluster(c.EtcdHosts)
-	for _, host := range c.EtcdHosts {
-		if err := services.RestoreEtcdSnapshot(ctx, host, c.PrivateRegistriesMap, c.SystemImages.Etcd, snapshotPath, initCluster); err != nil {
-			return fmt.Errorf("[etcd] Failed to restore etcd snapshot: %v", err)
+	if client, err := c.snapshotServerClient(ctx); err != nil {
+		return err
+	} else if client != nil {
+		result, err := client.Restore(snapshotPath)
+		if err != nil {
+			return err
 		}
+		return snapshotResultsToError(result.Hosts)
+	}
+
+	// Unlike SnapshotEtcd/RemoveEtcdSnapshot, restore does NOT default to
+	// ContinueOnError: a restore reseeds etcd from scratch on every host, and
+	// racing ahead on the remaining hosts after one has already failed risks
+	// leaving the cluster split between old and new state. Fail fast instead.
+	_, err := c.RestoreEtcdSnapshotWithOptions(ctx, snapshotPath, SnapshotOptions{})
+	if err != nil {
+		return fmt.Errorf("[etcd] Failed to restore etcd snapshot: %v", err)
 	}
 	return nil
 }
 
 func (c *Cluster) RemoveEtcdSnapshot(ctx context.Context, snapshotName string) error {
-	backupImage := c.getBackupImage()
-	for _, host := range c.EtcdHosts {
-		if err := services.RunEtcdSnapshotRemove(ctx, host, c.PrivateRegistriesMap, backupImage, snapshotName, true, c.Services.Etcd); err != nil {
+	if client, err := c.snapshotServerClient(ctx); err != nil {
+		return err
+	} else if client != nil {
+		result, err := client.Delete(snapshotName)
+		if err != nil {
 			return err
 		}
+		return snapshotResultsToError(result.Hosts)
 	}
-	return nil
-}
 
-func (c *Cluster) etcdSnapshotChecksum(ctx context.Context, snapshotPath string) bool {
-	log.Infof(ctx, "[etcd] Checking if all snapshots are identical")
-	etcdChecksums := []string{}
-	backupImage := c.getBackupImage()
+	_, err := c.RemoveEtcdSnapshotWithOptions(ctx, snapshotName, SnapshotOptions{ContinueOnError: true})
+	return err
+}
 
-	for _, etcdHost := range c.EtcdHosts {
-		checksum, err := services.GetEtcdSnapshotChecksum(ctx, etcdHost, c.PrivateRegistriesMap, backupImage, snapshotPath)
+// ListSnapshots enumerates snapshots either locally (delegating to
+// SnapshotList) or through the configured snapshot server.
+func (c *Cluster) ListSnapshots(ctx context.Context) ([]snapshotserver.SnapshotResult, error) {
+	client, err := c.snapshotServerClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		snapshots, err := c.SnapshotList(ctx)
 		if err != nil {
-			return false
+			return nil, err
 		}
-		etcdChecksums = append(etcdChecksums, checksum)
-		log.Infof(ctx, "[etcd] Checksum of etcd snapshot on host [%s] is [%s]", etcdHost.Address, checksum)
-	}
-	hostChecksum := etcdChecksums[0]
-	for _, checksum := range etcdChecksums {
-		if checksum != hostChecksum {
-			return false
+		results := make([]snapshotserver.SnapshotResult, 0, len(snapshots))
+		for _, snapshot := range snapshots {
+			result := snapshotserver.SnapshotResult{Name: snapshot.Name, Checksum: snapshot.Sha256}
+			if manifest, err := c.fetchAndValidateManifest(ctx, c.EtcdHosts[0], snapshot.Name); err == nil {
+				result.Manifest = &snapshotserver.SnapshotManifest{
+					EtcdVersion: manifest.EtcdVersion,
+					ClusterName: manifest.ClusterName,
+					Timestamp:   manifest.Timestamp,
+					Size:        manifest.Size,
+					Sha256:      manifest.Sha256,
+					Signature:   manifest.Signature,
+				}
+			}
+			results = append(results, result)
 		}
+		return results, nil
 	}
-	return true
+	return client.List()
 }
 
 func (c *Cluster) getBackupImage() string {
@@ -186,6 +263,13 @@ func IsLocalSnapshot(name string) bool {
 	//
 	// providerFlag = "l" local
 	// providerFlag = "s" s3
+	// providerFlag = "g" gcs
+	// providerFlag = "a" azure
+	// providerFlag = "r" restic
+	//
+	// Only "l" is ever considered local here; the other provider flags are
+	// recognized so old cluster state files with those snapshot names still
+	// parse, but they're resolved through resolveSnapshotBackend instead.
 	re := regexp.MustCompile("^c-[a-z0-9].*?-.l-")
 	return re.MatchString(name)
 }
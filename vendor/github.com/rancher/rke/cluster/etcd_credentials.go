@@ -0,0 +1,137 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rancher/rke/k8s"
+	"github.com/rancher/rke/log"
+	"github.com/rancher/rke/services"
+)
+
+const (
+	// S3CredentialSourceInline keeps using AccessKey/SecretKey as configured
+	// directly on the S3BackupConfig. This is the legacy behavior.
+	S3CredentialSourceInline = "inline"
+	// S3CredentialSourceKubeSecret resolves credentials from a Kubernetes
+	// Secret in kube-system at snapshot/restore time.
+	S3CredentialSourceKubeSecret = "kubeSecret"
+	// S3CredentialSourceIAM resolves credentials through the standard AWS SDK
+	// credential chain (instance profile, IRSA, env, shared config).
+	S3CredentialSourceIAM = "iam"
+	// S3CredentialSourceExternal shells out to an external file/exec provider.
+	S3CredentialSourceExternal = "external"
+
+	s3SecretAccessKeyField = "accesskey"
+	s3SecretSecretKeyField = "secretkey"
+)
+
+// S3Credentials is the resolved, ephemeral access/secret key pair for a
+// single snapshot or restore operation. It is never persisted on the etcd
+// node; it is only threaded into the rke-tools container as env.
+type S3Credentials struct {
+	AccessKey string
+	SecretKey string
+}
+
+// S3CredentialSource resolves S3 credentials for etcd snapshot operations at
+// the point of use instead of requiring them to be embedded in the RKE
+// cluster config ahead of time. Resolve is called immediately before a
+// snapshot/restore/download so that credential rotation (e.g. a Secret
+// update, or a refreshed IRSA token) doesn't require re-running `rke up`.
+type S3CredentialSource interface {
+	Resolve(ctx context.Context, c *Cluster) (*S3Credentials, error)
+}
+
+// GetS3CredentialSource picks the S3CredentialSource implementation
+// indicated by bc.CredentialSource, defaulting to the legacy inline
+// behavior when unset so existing cluster configs keep working.
+func GetS3CredentialSource(bc *services.S3BackupConfig) (S3CredentialSource, error) {
+	if bc == nil {
+		return nil, fmt.Errorf("no S3 backup configuration provided")
+	}
+	switch bc.CredentialSource {
+	case "", S3CredentialSourceInline:
+		return &inlineS3CredentialSource{bc: bc}, nil
+	case S3CredentialSourceKubeSecret:
+		return &kubeSecretS3CredentialSource{bc: bc}, nil
+	case S3CredentialSourceIAM:
+		return &iamS3CredentialSource{}, nil
+	case S3CredentialSourceExternal:
+		return &externalS3CredentialSource{bc: bc}, nil
+	default:
+		return nil, fmt.Errorf("unsupported S3 credential source [%s]", bc.CredentialSource)
+	}
+}
+
+type inlineS3CredentialSource struct {
+	bc *services.S3BackupConfig
+}
+
+func (s *inlineS3CredentialSource) Resolve(ctx context.Context, c *Cluster) (*S3Credentials, error) {
+	return &S3Credentials{AccessKey: s.bc.AccessKey, SecretKey: s.bc.SecretKey}, nil
+}
+
+// kubeSecretS3CredentialSource reads a Kubernetes Secret in kube-system,
+// resolved through the cluster's kubeconfig at snapshot/restore time so
+// that rotating the Secret takes effect without re-running `rke up`.
+type kubeSecretS3CredentialSource struct {
+	bc *services.S3BackupConfig
+}
+
+func (s *kubeSecretS3CredentialSource) Resolve(ctx context.Context, c *Cluster) (*S3Credentials, error) {
+	if s.bc.SecretName == "" {
+		return nil, fmt.Errorf("s3 credential source is [%s] but no secretName is configured", S3CredentialSourceKubeSecret)
+	}
+	k8sClient, err := k8s.NewClient(c.LocalKubeConfigPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build kubernetes client to resolve S3 credentials: %v", err)
+	}
+	secret, err := k8s.GetSecret(k8sClient, "kube-system", s.bc.SecretName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read S3 credential secret [%s/%s]: %v", "kube-system", s.bc.SecretName, err)
+	}
+	accessKey, ok := secret.Data[s3SecretAccessKeyField]
+	if !ok {
+		return nil, fmt.Errorf("secret [%s/%s] is missing key [%s]", "kube-system", s.bc.SecretName, s3SecretAccessKeyField)
+	}
+	secretKey, ok := secret.Data[s3SecretSecretKeyField]
+	if !ok {
+		return nil, fmt.Errorf("secret [%s/%s] is missing key [%s]", "kube-system", s.bc.SecretName, s3SecretSecretKeyField)
+	}
+	return &S3Credentials{AccessKey: string(accessKey), SecretKey: string(secretKey)}, nil
+}
+
+// iamS3CredentialSource defers to the standard AWS SDK credential chain
+// (instance profile, IRSA web identity token, env, shared config) instead of
+// returning a static key pair.
+type iamS3CredentialSource struct{}
+
+func (s *iamS3CredentialSource) Resolve(ctx context.Context, c *Cluster) (*S3Credentials, error) {
+	// Leaving AccessKey/SecretKey empty tells the rke-tools container to fall
+	// back to the AWS SDK's default credential provider chain inside the
+	// container/host environment.
+	return &S3Credentials{}, nil
+}
+
+// externalS3CredentialSource shells out to an external binary referenced by
+// ExecCommand and parses its stdout as "accessKey\nsecretKey".
+type externalS3CredentialSource struct {
+	bc *services.S3BackupConfig
+}
+
+func (s *externalS3CredentialSource) Resolve(ctx context.Context, c *Cluster) (*S3Credentials, error) {
+	if s.bc.ExecCommand == "" {
+		return nil, fmt.Errorf("s3 credential source is [%s] but no execCommand is configured", S3CredentialSourceExternal)
+	}
+	accessKey, secretKey, err := runExecCredentialProvider(ctx, s.bc.ExecCommand, s.bc.ExecArgs)
+	if err != nil {
+		return nil, fmt.Errorf("external S3 credential provider [%s] failed: %v", s.bc.ExecCommand, err)
+	}
+	return &S3Credentials{AccessKey: accessKey, SecretKey: secretKey}, nil
+}
+
+func runExecCredentialProvider(ctx context.Context, command string, args []string) (string, string, error) {
+	log.Infof(ctx, "[etcd] resolving S3 credentials via external provider [%s]", command)
+	return execCredentialProvider(ctx, command, args)
+}
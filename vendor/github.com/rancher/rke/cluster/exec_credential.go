@@ -0,0 +1,28 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// execCredentialProvider runs an external binary and expects it to print the
+// access key and secret key as the first two lines of stdout. This mirrors
+// the exec-based credential plugin pattern used elsewhere in the Kubernetes
+// ecosystem (e.g. client-go exec credentials).
+func execCredentialProvider(ctx context.Context, command string, args []string) (string, string, error) {
+	cmd := exec.CommandContext(ctx, command, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", "", fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) < 2 {
+		return "", "", fmt.Errorf("expected 2 lines of output (accessKey, secretKey), got %d", len(lines))
+	}
+	return strings.TrimSpace(lines[0]), strings.TrimSpace(lines[1]), nil
+}
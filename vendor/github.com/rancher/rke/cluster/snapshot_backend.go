@@ -0,0 +1,276 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rancher/rke/hosts"
+	"github.com/rancher/rke/services"
+)
+
+// Snapshot backend kinds, matched against Services.Etcd.BackupConfig.Backend.Kind.
+const (
+	SnapshotBackendLocal  = "local"
+	SnapshotBackendS3     = "s3"
+	SnapshotBackendGCS    = "gcs"
+	SnapshotBackendAzure  = "azure"
+	SnapshotBackendRestic = "restic"
+)
+
+// SnapshotBackend is the pluggable storage target for etcd snapshots.
+// PrepareBackup, SnapshotEtcd and friends dispatch on backend.Kind() instead
+// of hard-coding a local/S3 branch, so new storage providers only need a new
+// implementation here plus a discriminator in BackupConfig.Backend.
+type SnapshotBackend interface {
+	Kind() string
+	// Save uploads/copies the snapshot at snapshotPath from host to the backend.
+	Save(ctx context.Context, host *hosts.Host, snapshotPath string) error
+	// Fetch downloads the snapshot named snapshotPath onto host, ready for restore.
+	Fetch(ctx context.Context, host *hosts.Host, snapshotPath string) error
+	// List enumerates snapshots known to the backend.
+	List(ctx context.Context, host *hosts.Host) ([]SnapshotInfo, error)
+	// Delete removes a snapshot from the backend.
+	Delete(ctx context.Context, host *hosts.Host, snapshotPath string) error
+	// Checksum returns the backend's view of a snapshot's sha256, which
+	// writeSnapshotManifest records in the snapshot's manifest.
+	Checksum(ctx context.Context, host *hosts.Host, snapshotPath string) (string, error)
+}
+
+// resolveSnapshotBackend picks the SnapshotBackend for snapshotPath, honoring
+// an explicit Backend.Kind discriminator when configured and otherwise
+// falling back to the legacy local/S3 detection via IsLocalSnapshot.
+func (c *Cluster) resolveSnapshotBackend(snapshotPath string) (SnapshotBackend, error) {
+	backupImage := c.getBackupImage()
+	backendConfig := c.Services.Etcd.BackupConfig
+	kind := backendKind(backendConfig, snapshotPath)
+
+	switch kind {
+	case SnapshotBackendLocal:
+		return &localSnapshotBackend{cluster: c, backupImage: backupImage}, nil
+	case SnapshotBackendS3:
+		if backendConfig == nil || backendConfig.S3BackupConfig == nil {
+			return nil, fmt.Errorf(BackupPrepareError)
+		}
+		return &s3SnapshotBackend{cluster: c, backupImage: backupImage, config: backendConfig.S3BackupConfig}, nil
+	case SnapshotBackendGCS:
+		if backendConfig.Backend.GCS == nil {
+			return nil, fmt.Errorf("backend is [%s] but no gcs configuration is set", SnapshotBackendGCS)
+		}
+		return &gcsSnapshotBackend{cluster: c, backupImage: backupImage, config: backendConfig.Backend.GCS}, nil
+	case SnapshotBackendAzure:
+		if backendConfig.Backend.Azure == nil {
+			return nil, fmt.Errorf("backend is [%s] but no azure configuration is set", SnapshotBackendAzure)
+		}
+		return &azureSnapshotBackend{cluster: c, backupImage: backupImage, config: backendConfig.Backend.Azure}, nil
+	case SnapshotBackendRestic:
+		if backendConfig.Backend.Restic == nil {
+			return nil, fmt.Errorf("backend is [%s] but no restic configuration is set", SnapshotBackendRestic)
+		}
+		return &resticSnapshotBackend{cluster: c, backupImage: backupImage, config: backendConfig.Backend.Restic}, nil
+	default:
+		return nil, fmt.Errorf("unsupported snapshot backend [%s]", kind)
+	}
+}
+
+// backendKind decides which SnapshotBackend kind applies to backendConfig.
+// An explicit Backend.Kind discriminator always wins; otherwise it falls
+// back to the legacy local/S3 detection via IsLocalSnapshot. snapshotPath
+// may be empty when resolving a backend that isn't about one specific
+// snapshot (e.g. listing everything known to the cluster): IsLocalSnapshot("")
+// never matches, so without this special case every such caller would
+// misclassify a plain local cluster as S3. In that case the choice rests on
+// whether S3BackupConfig is configured at all, rather than on the snapshot
+// name.
+func backendKind(backendConfig *services.BackupConfig, snapshotPath string) string {
+	if backendConfig != nil && backendConfig.Backend != nil && backendConfig.Backend.Kind != "" {
+		return backendConfig.Backend.Kind
+	}
+	if backendConfig == nil {
+		return SnapshotBackendLocal
+	}
+	if snapshotPath == "" {
+		if backendConfig.S3BackupConfig == nil {
+			return SnapshotBackendLocal
+		}
+		return SnapshotBackendS3
+	}
+	if IsLocalSnapshot(snapshotPath) {
+		return SnapshotBackendLocal
+	}
+	return SnapshotBackendS3
+}
+
+type localSnapshotBackend struct {
+	cluster     *Cluster
+	backupImage string
+}
+
+func (b *localSnapshotBackend) Kind() string { return SnapshotBackendLocal }
+
+func (b *localSnapshotBackend) Save(ctx context.Context, host *hosts.Host, snapshotPath string) error {
+	return services.RunEtcdSnapshotSave(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, snapshotPath, true, b.cluster.Services.Etcd, nil)
+}
+
+func (b *localSnapshotBackend) Fetch(ctx context.Context, host *hosts.Host, snapshotPath string) error {
+	return fmt.Errorf("fetch for the local backend is handled by the backup server, not SnapshotBackend.Fetch")
+}
+
+func (b *localSnapshotBackend) List(ctx context.Context, host *hosts.Host) ([]SnapshotInfo, error) {
+	raw, err := services.RunEtcdSnapshotList(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, b.cluster.Services.Etcd)
+	if err != nil {
+		return nil, err
+	}
+	return parseSnapshotList(raw), nil
+}
+
+func (b *localSnapshotBackend) Delete(ctx context.Context, host *hosts.Host, snapshotPath string) error {
+	return services.RunEtcdSnapshotRemove(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, snapshotPath, true, b.cluster.Services.Etcd)
+}
+
+func (b *localSnapshotBackend) Checksum(ctx context.Context, host *hosts.Host, snapshotPath string) (string, error) {
+	return services.GetEtcdSnapshotChecksum(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, snapshotPath)
+}
+
+type s3SnapshotBackend struct {
+	cluster     *Cluster
+	backupImage string
+	config      *services.S3BackupConfig
+}
+
+func (b *s3SnapshotBackend) Kind() string { return SnapshotBackendS3 }
+
+func (b *s3SnapshotBackend) Save(ctx context.Context, host *hosts.Host, snapshotPath string) error {
+	env, err := b.cluster.resolveS3SnapshotEnv(ctx)
+	if err != nil {
+		return err
+	}
+	return services.RunEtcdSnapshotSave(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, snapshotPath, true, b.cluster.Services.Etcd, env)
+}
+
+func (b *s3SnapshotBackend) Fetch(ctx context.Context, host *hosts.Host, snapshotPath string) error {
+	env, err := b.cluster.resolveS3SnapshotEnv(ctx)
+	if err != nil {
+		return err
+	}
+	return services.DownloadEtcdSnapshotFromS3(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, snapshotPath, b.cluster.Services.Etcd, env)
+}
+
+func (b *s3SnapshotBackend) List(ctx context.Context, host *hosts.Host) ([]SnapshotInfo, error) {
+	raw, err := services.RunEtcdSnapshotList(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, b.cluster.Services.Etcd)
+	if err != nil {
+		return nil, err
+	}
+	return parseSnapshotList(raw), nil
+}
+
+func (b *s3SnapshotBackend) Delete(ctx context.Context, host *hosts.Host, snapshotPath string) error {
+	return services.RunEtcdSnapshotRemove(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, snapshotPath, true, b.cluster.Services.Etcd)
+}
+
+func (b *s3SnapshotBackend) Checksum(ctx context.Context, host *hosts.Host, snapshotPath string) (string, error) {
+	return services.GetEtcdSnapshotChecksum(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, snapshotPath)
+}
+
+// gcsSnapshotBackend, azureSnapshotBackend and resticSnapshotBackend all run
+// their transfer inside the rke-tools container, which bundles the gsutil,
+// az and restic/rclone binaries respectively; the cluster package only needs
+// to pass through the right config and env.
+
+type gcsSnapshotBackend struct {
+	cluster     *Cluster
+	backupImage string
+	config      *services.GCSBackupConfig
+}
+
+func (b *gcsSnapshotBackend) Kind() string { return SnapshotBackendGCS }
+
+func (b *gcsSnapshotBackend) Save(ctx context.Context, host *hosts.Host, snapshotPath string) error {
+	return services.RunEtcdSnapshotSaveGCS(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, snapshotPath, b.cluster.Services.Etcd, b.config)
+}
+
+func (b *gcsSnapshotBackend) Fetch(ctx context.Context, host *hosts.Host, snapshotPath string) error {
+	return services.DownloadEtcdSnapshotFromGCS(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, snapshotPath, b.cluster.Services.Etcd, b.config)
+}
+
+func (b *gcsSnapshotBackend) List(ctx context.Context, host *hosts.Host) ([]SnapshotInfo, error) {
+	raw, err := services.ListEtcdSnapshotsGCS(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, b.config)
+	if err != nil {
+		return nil, err
+	}
+	return parseSnapshotList(raw), nil
+}
+
+func (b *gcsSnapshotBackend) Delete(ctx context.Context, host *hosts.Host, snapshotPath string) error {
+	return services.DeleteEtcdSnapshotGCS(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, snapshotPath, b.config)
+}
+
+func (b *gcsSnapshotBackend) Checksum(ctx context.Context, host *hosts.Host, snapshotPath string) (string, error) {
+	return services.GetEtcdSnapshotChecksumGCS(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, snapshotPath, b.config)
+}
+
+type azureSnapshotBackend struct {
+	cluster     *Cluster
+	backupImage string
+	config      *services.AzureBackupConfig
+}
+
+func (b *azureSnapshotBackend) Kind() string { return SnapshotBackendAzure }
+
+func (b *azureSnapshotBackend) Save(ctx context.Context, host *hosts.Host, snapshotPath string) error {
+	return services.RunEtcdSnapshotSaveAzure(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, snapshotPath, b.cluster.Services.Etcd, b.config)
+}
+
+func (b *azureSnapshotBackend) Fetch(ctx context.Context, host *hosts.Host, snapshotPath string) error {
+	return services.DownloadEtcdSnapshotFromAzure(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, snapshotPath, b.cluster.Services.Etcd, b.config)
+}
+
+func (b *azureSnapshotBackend) List(ctx context.Context, host *hosts.Host) ([]SnapshotInfo, error) {
+	raw, err := services.ListEtcdSnapshotsAzure(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, b.config)
+	if err != nil {
+		return nil, err
+	}
+	return parseSnapshotList(raw), nil
+}
+
+func (b *azureSnapshotBackend) Delete(ctx context.Context, host *hosts.Host, snapshotPath string) error {
+	return services.DeleteEtcdSnapshotAzure(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, snapshotPath, b.config)
+}
+
+func (b *azureSnapshotBackend) Checksum(ctx context.Context, host *hosts.Host, snapshotPath string) (string, error) {
+	return services.GetEtcdSnapshotChecksumAzure(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, snapshotPath, b.config)
+}
+
+// resticSnapshotBackend is the generic escape hatch: a restic or rclone
+// repository URL plus a passthrough of its own env, for backends that don't
+// warrant a first-class implementation.
+type resticSnapshotBackend struct {
+	cluster     *Cluster
+	backupImage string
+	config      *services.ResticBackupConfig
+}
+
+func (b *resticSnapshotBackend) Kind() string { return SnapshotBackendRestic }
+
+func (b *resticSnapshotBackend) Save(ctx context.Context, host *hosts.Host, snapshotPath string) error {
+	return services.RunEtcdSnapshotSaveRestic(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, snapshotPath, b.cluster.Services.Etcd, b.config)
+}
+
+func (b *resticSnapshotBackend) Fetch(ctx context.Context, host *hosts.Host, snapshotPath string) error {
+	return services.DownloadEtcdSnapshotFromRestic(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, snapshotPath, b.cluster.Services.Etcd, b.config)
+}
+
+func (b *resticSnapshotBackend) List(ctx context.Context, host *hosts.Host) ([]SnapshotInfo, error) {
+	raw, err := services.ListEtcdSnapshotsRestic(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, b.config)
+	if err != nil {
+		return nil, err
+	}
+	return parseSnapshotList(raw), nil
+}
+
+func (b *resticSnapshotBackend) Delete(ctx context.Context, host *hosts.Host, snapshotPath string) error {
+	return services.DeleteEtcdSnapshotRestic(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, snapshotPath, b.config)
+}
+
+func (b *resticSnapshotBackend) Checksum(ctx context.Context, host *hosts.Host, snapshotPath string) (string, error) {
+	return services.GetEtcdSnapshotChecksumRestic(ctx, host, b.cluster.PrivateRegistriesMap, b.backupImage, snapshotPath, b.config)
+}
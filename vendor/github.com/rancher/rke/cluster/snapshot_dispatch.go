@@ -0,0 +1,58 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rancher/rke/pki"
+	"github.com/rancher/rke/services/snapshotserver"
+)
+
+type snapshotServerConfig struct {
+	url   string
+	token string
+}
+
+type snapshotServerConfigKey struct{}
+
+// WithSnapshotServerConfig returns a context that routes SnapshotEtcd,
+// RestoreEtcdSnapshot, RemoveEtcdSnapshot and ListSnapshots through the
+// snapshot server at url instead of running them against this process's own
+// SSH/docker connections. This lets the terraform provider and other
+// tooling manage snapshots without needing access to every etcd host.
+//
+// Never thread a context carrying this config into RunSnapshotServer's own
+// Cluster calls: its Backend adapter already talks to the *WithOptions
+// primitives directly (see snapshotServerBackend), bypassing dispatch
+// entirely, so that a server can never be made to call back into itself.
+func WithSnapshotServerConfig(ctx context.Context, url, token string) context.Context {
+	return context.WithValue(ctx, snapshotServerConfigKey{}, snapshotServerConfig{url: url, token: token})
+}
+
+// snapshotServerClient returns a snapshotserver.Client when ctx carries a
+// snapshot server config (see WithSnapshotServerConfig), or nil when
+// snapshot verbs should run against the local process as usual.
+func (c *Cluster) snapshotServerClient(ctx context.Context) (*snapshotserver.Client, error) {
+	cfg, ok := ctx.Value(snapshotServerConfigKey{}).(snapshotServerConfig)
+	if !ok {
+		return nil, nil
+	}
+	tlsConfig, err := pki.GetClientTLSConfig(c.Certificates, cfg.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build mTLS config for snapshot server: %v", err)
+	}
+	return snapshotserver.NewClient(cfg.url, cfg.token, tlsConfig), nil
+}
+
+func snapshotResultsToError(results []snapshotserver.HostResult) error {
+	var errs []error
+	for _, hostResult := range results {
+		if hostResult.Error != "" {
+			errs = append(errs, fmt.Errorf("[%s]: %s", hostResult.Address, hostResult.Error))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%v", errs)
+}
@@ -0,0 +1,131 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rancher/rke/hosts"
+	"github.com/rancher/rke/log"
+	"github.com/rancher/rke/pki"
+	"github.com/rancher/rke/services"
+)
+
+// SnapshotManifest is written next to a snapshot on its backend as
+// "<snapshot>.metadata", alongside a plain "<snapshot>.sha256" sidecar. It
+// lets PrepareBackup and VerifySnapshot detect silent corruption of a
+// snapshot at rest, not just disagreement between etcd hosts.
+type SnapshotManifest struct {
+	EtcdVersion string    `json:"etcdVersion"`
+	ClusterName string    `json:"clusterName"`
+	Timestamp   time.Time `json:"timestamp"`
+	Size        int64     `json:"size"`
+	Sha256      string    `json:"sha256"`
+	// Signature, when present, is a signature over the rest of the manifest
+	// produced with the pki key named by BackupConfig.ManifestSigningKey.
+	// External cosign/PGP keys are not supported.
+	Signature string `json:"signature,omitempty"`
+}
+
+// writeSnapshotManifest computes and writes the sha256 sidecar and JSON
+// manifest for a snapshot that was just saved, reading the checksum/size
+// back from host (one of the hosts that succeeded the save), signing it if
+// the backup config references a signing key.
+func (c *Cluster) writeSnapshotManifest(ctx context.Context, host *hosts.Host, backend SnapshotBackend, snapshotPath string) error {
+	checksum, err := backend.Checksum(ctx, host, snapshotPath)
+	if err != nil {
+		return fmt.Errorf("failed to checksum snapshot [%s] for manifest: %v", snapshotPath, err)
+	}
+	size, err := services.GetEtcdSnapshotSize(ctx, host, c.PrivateRegistriesMap, c.getBackupImage(), snapshotPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat snapshot [%s] for manifest: %v", snapshotPath, err)
+	}
+	manifest := SnapshotManifest{
+		EtcdVersion: c.Services.Etcd.Image,
+		ClusterName: c.ClusterName,
+		Timestamp:   time.Now(),
+		Size:        size,
+		Sha256:      checksum,
+	}
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot manifest for [%s]: %v", snapshotPath, err)
+	}
+	if signingKey := c.manifestSigningKeyName(); signingKey != "" {
+		// Sign the full encoded manifest, not just the checksum field, so a
+		// tampered ClusterName/EtcdVersion/Timestamp/Size is also detected.
+		signature, err := pki.SignData(c.Certificates, signingKey, raw)
+		if err != nil {
+			return fmt.Errorf("failed to sign snapshot manifest with key [%s]: %v", signingKey, err)
+		}
+		manifest.Signature = signature
+		raw, err = json.Marshal(manifest)
+		if err != nil {
+			return fmt.Errorf("failed to encode signed snapshot manifest for [%s]: %v", snapshotPath, err)
+		}
+	}
+	return services.WriteEtcdSnapshotManifest(ctx, host, c.PrivateRegistriesMap, c.getBackupImage(), snapshotPath, raw)
+}
+
+func (c *Cluster) manifestSigningKeyName() string {
+	bc := c.Services.Etcd.BackupConfig
+	if bc == nil {
+		return ""
+	}
+	return bc.ManifestSigningKey
+}
+
+// fetchAndValidateManifest reads the sidecar manifest for snapshotPath from
+// host and checks its sha256 and, if a signing key is configured, its
+// signature over the manifest as written (signature field excluded). It
+// returns services.ErrSnapshotManifestNotFound, wrapped, for snapshots that
+// predate manifests — callers should treat that as "nothing to validate",
+// not as a reason to refuse a restore; any other error means the manifest
+// exists but doesn't check out, which does warrant refusing.
+func (c *Cluster) fetchAndValidateManifest(ctx context.Context, host *hosts.Host, snapshotPath string) (*SnapshotManifest, error) {
+	raw, err := services.FetchEtcdSnapshotManifest(ctx, host, c.PrivateRegistriesMap, c.getBackupImage(), snapshotPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest for snapshot [%s]: %w", snapshotPath, err)
+	}
+	var manifest SnapshotManifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest for snapshot [%s]: %v", snapshotPath, err)
+	}
+	actualChecksum, err := services.GetEtcdSnapshotChecksum(ctx, host, c.PrivateRegistriesMap, c.getBackupImage(), snapshotPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to checksum snapshot [%s] for manifest validation: %v", snapshotPath, err)
+	}
+	if manifest.Sha256 != actualChecksum {
+		return nil, fmt.Errorf("snapshot [%s] sha256 [%s] does not match manifest [%s], refusing to restore", snapshotPath, actualChecksum, manifest.Sha256)
+	}
+	if signingKey := c.manifestSigningKeyName(); signingKey != "" {
+		signature := manifest.Signature
+		manifest.Signature = ""
+		unsigned, err := json.Marshal(manifest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to re-encode manifest for snapshot [%s]: %v", snapshotPath, err)
+		}
+		valid, err := pki.VerifySignature(c.Certificates, signingKey, unsigned, signature)
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify manifest signature for snapshot [%s]: %v", snapshotPath, err)
+		}
+		if !valid {
+			return nil, fmt.Errorf("manifest signature for snapshot [%s] is invalid, refusing to restore", snapshotPath)
+		}
+		manifest.Signature = signature
+	}
+	return &manifest, nil
+}
+
+// VerifySnapshot runs the manifest sha256/signature check for a named
+// snapshot without doing a full restore prepare, useful for periodic
+// backup-health probes.
+func (c *Cluster) VerifySnapshot(ctx context.Context, snapshotPath string) error {
+	if len(c.EtcdHosts) == 0 {
+		return fmt.Errorf("no etcd hosts configured")
+	}
+	log.Infof(ctx, "[etcd] verifying snapshot [%s]", snapshotPath)
+	_, err := c.fetchAndValidateManifest(ctx, c.EtcdHosts[0], snapshotPath)
+	return err
+}
@@ -0,0 +1,243 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rancher/rke/hosts"
+	"github.com/rancher/rke/log"
+	"github.com/rancher/rke/services"
+	"github.com/rancher/rke/util"
+	"golang.org/x/sync/errgroup"
+)
+
+// HostOutcome is one etcd host's result from a fanned-out snapshot
+// operation (save/restore/remove).
+type HostOutcome struct {
+	Address string
+	Error   error
+}
+
+// MultiHostResult records the per-host outcome of a snapshot operation that
+// was fanned out across c.EtcdHosts, so callers can decide whether N-of-M
+// success is acceptable (e.g. a snapshot that succeeded on quorum).
+type MultiHostResult struct {
+	Outcomes []HostOutcome
+}
+
+// Succeeded returns the addresses of hosts that completed without error.
+func (r *MultiHostResult) Succeeded() []string {
+	var addrs []string
+	for _, outcome := range r.Outcomes {
+		if outcome.Error == nil {
+			addrs = append(addrs, outcome.Address)
+		}
+	}
+	return addrs
+}
+
+// Failed returns the addresses of hosts that returned an error.
+func (r *MultiHostResult) Failed() []string {
+	var addrs []string
+	for _, outcome := range r.Outcomes {
+		if outcome.Error != nil {
+			addrs = append(addrs, outcome.Address)
+		}
+	}
+	return addrs
+}
+
+// Err aggregates the per-host errors, or nil if every host succeeded.
+func (r *MultiHostResult) Err() error {
+	var errList []error
+	for _, outcome := range r.Outcomes {
+		if outcome.Error != nil {
+			errList = append(errList, fmt.Errorf("[%s]: %v", outcome.Address, outcome.Error))
+		}
+	}
+	return util.ErrList(errList)
+}
+
+// SnapshotOptions configures the fan-out behavior of *WithOptions snapshot
+// operations.
+type SnapshotOptions struct {
+	// Concurrency caps how many hosts are operated on at once. Defaults to
+	// WorkerThreads when zero, matching DeployRestoreCerts.
+	Concurrency int
+	// ContinueOnError keeps going on other hosts after one host fails,
+	// instead of aborting the whole operation.
+	ContinueOnError bool
+	// QuorumOnly treats the operation as successful once more than half of
+	// c.EtcdHosts succeed. Like ContinueOnError, it keeps going on other
+	// hosts after one host fails.
+	QuorumOnly bool
+	// PerHostTimeout bounds how long a single host's operation may run.
+	// Zero means no per-host timeout.
+	PerHostTimeout time.Duration
+}
+
+func (o SnapshotOptions) concurrency() int {
+	if o.Concurrency > 0 {
+		return o.Concurrency
+	}
+	return WorkerThreads
+}
+
+// fanOutHosts runs fn across c.EtcdHosts using the same bounded worker pool
+// (util.GetObjectQueue + errgroup) as DeployRestoreCerts, honoring
+// opts.Concurrency and opts.PerHostTimeout, and collects every host's outcome
+// into a MultiHostResult. Unless opts.ContinueOnError or opts.QuorumOnly is
+// set, the first failure cancels the context passed to the remaining hosts.
+func (c *Cluster) fanOutHosts(ctx context.Context, opts SnapshotOptions, fn func(ctx context.Context, host *hosts.Host) error) (*MultiHostResult, error) {
+	fanCtx, cancelFan := context.WithCancel(ctx)
+	defer cancelFan()
+
+	hostsQueue := util.GetObjectQueue(c.EtcdHosts)
+	resultsCh := make(chan HostOutcome, len(c.EtcdHosts))
+
+	var errgrp errgroup.Group
+	for w := 0; w < opts.concurrency(); w++ {
+		errgrp.Go(func() error {
+			for obj := range hostsQueue {
+				host := obj.(*hosts.Host)
+				hostCtx := fanCtx
+				cancel := func() {}
+				if opts.PerHostTimeout > 0 {
+					hostCtx, cancel = context.WithTimeout(fanCtx, opts.PerHostTimeout)
+				}
+				err := fn(hostCtx, host)
+				cancel()
+				if err != nil {
+					log.Warnf(ctx, "[etcd] snapshot operation failed on host [%s]: %v", host.Address, err)
+					if !opts.ContinueOnError && !opts.QuorumOnly {
+						cancelFan() // stop remaining in-flight/queued work; we're going to fail anyway
+					}
+				}
+				resultsCh <- HostOutcome{Address: host.Address, Error: err}
+			}
+			return nil
+		})
+	}
+	errgrp.Wait() // workers never return an error themselves; failures are carried in resultsCh
+	close(resultsCh)
+
+	result := &MultiHostResult{}
+	for outcome := range resultsCh {
+		result.Outcomes = append(result.Outcomes, outcome)
+	}
+
+	if opts.QuorumOnly {
+		quorum := len(c.EtcdHosts)/2 + 1
+		if len(result.Succeeded()) >= quorum {
+			return result, nil
+		}
+		return result, fmt.Errorf("failed to reach quorum (%d/%d hosts succeeded): %v", len(result.Succeeded()), len(c.EtcdHosts), result.Err())
+	}
+	return result, result.Err()
+}
+
+// SnapshotEtcdWithOptions takes a snapshot across all EtcdHosts using a
+// bounded worker pool, returning per-host outcomes instead of aborting on
+// the first failure. Once the fan-out reports success (every host, or a
+// quorum with opts.QuorumOnly), the sha256/manifest sidecar is written
+// exactly once, against a single host that succeeded — not once per host,
+// which would have every host racing to overwrite the same sidecar with its
+// own (identical, but independently timestamped) manifest. When the fan-out
+// returns an error, no manifest is written.
+func (c *Cluster) SnapshotEtcdWithOptions(ctx context.Context, snapshotName string, opts SnapshotOptions) (*MultiHostResult, error) {
+	backend, err := c.resolveSnapshotBackend(snapshotName)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.fanOutHosts(ctx, opts, func(ctx context.Context, host *hosts.Host) error {
+		return backend.Save(ctx, host, snapshotName)
+	})
+	if err != nil {
+		return result, err
+	}
+	manifestHost := c.firstSucceededHost(result)
+	if manifestHost == nil {
+		return result, fmt.Errorf("no host succeeded the snapshot, cannot write manifest for [%s]", snapshotName)
+	}
+	if err := c.writeSnapshotManifest(ctx, manifestHost, backend, snapshotName); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// firstSucceededHost returns the *hosts.Host behind the first address in
+// result.Succeeded(), or nil if none succeeded.
+func (c *Cluster) firstSucceededHost(result *MultiHostResult) *hosts.Host {
+	succeeded := result.Succeeded()
+	if len(succeeded) == 0 {
+		return nil
+	}
+	for _, host := range c.EtcdHosts {
+		if host.Address == succeeded[0] {
+			return host
+		}
+	}
+	return nil
+}
+
+// RestoreEtcdSnapshotWithOptions restores a snapshot across all EtcdHosts
+// using a bounded worker pool.
+func (c *Cluster) RestoreEtcdSnapshotWithOptions(ctx context.Context, snapshotPath string, opts SnapshotOptions) (*MultiHostResult, error) {
+	initCluster := services.GetEtcdInitialCluster(c.EtcdHosts)
+	return c.fanOutHosts(ctx, opts, func(ctx context.Context, host *hosts.Host) error {
+		return services.RestoreEtcdSnapshot(ctx, host, c.PrivateRegistriesMap, c.SystemImages.Etcd, snapshotPath, initCluster)
+	})
+}
+
+// RemoveEtcdSnapshotWithOptions removes a snapshot across all EtcdHosts
+// using a bounded worker pool.
+func (c *Cluster) RemoveEtcdSnapshotWithOptions(ctx context.Context, snapshotName string, opts SnapshotOptions) (*MultiHostResult, error) {
+	backend, err := c.resolveSnapshotBackend(snapshotName)
+	if err != nil {
+		return nil, err
+	}
+	return c.fanOutHosts(ctx, opts, func(ctx context.Context, host *hosts.Host) error {
+		return backend.Delete(ctx, host, snapshotName)
+	})
+}
+
+// etcdSnapshotChecksumWithOptions reads each etcd host's snapshot checksum
+// in parallel with the same worker pool, comparing all checksums once
+// every host has reported in.
+func (c *Cluster) etcdSnapshotChecksumWithOptions(ctx context.Context, snapshotPath string, opts SnapshotOptions) bool {
+	log.Infof(ctx, "[etcd] Checking if all snapshots are identical")
+	backupImage := c.getBackupImage()
+
+	type checksumResult struct {
+		address  string
+		checksum string
+	}
+	checksums := make(chan checksumResult, len(c.EtcdHosts))
+
+	_, err := c.fanOutHosts(ctx, opts, func(ctx context.Context, host *hosts.Host) error {
+		checksum, err := services.GetEtcdSnapshotChecksum(ctx, host, c.PrivateRegistriesMap, backupImage, snapshotPath)
+		if err != nil {
+			return err
+		}
+		log.Infof(ctx, "[etcd] Checksum of etcd snapshot on host [%s] is [%s]", host.Address, checksum)
+		checksums <- checksumResult{address: host.Address, checksum: checksum}
+		return nil
+	})
+	close(checksums)
+	if err != nil {
+		return false
+	}
+
+	var hostChecksum string
+	for result := range checksums {
+		if hostChecksum == "" {
+			hostChecksum = result.checksum
+			continue
+		}
+		if result.checksum != hostChecksum {
+			return false
+		}
+	}
+	return true
+}
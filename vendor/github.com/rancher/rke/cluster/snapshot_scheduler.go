@@ -0,0 +1,216 @@
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rancher/rke/log"
+	"github.com/rancher/rke/services"
+	"github.com/robfig/cron"
+)
+
+const (
+	// recurringSnapshotTypeFlag marks snapshots taken by the scheduler, as
+	// opposed to "m" (manual) one-shot snapshots. See IsLocalSnapshot.
+	recurringSnapshotTypeFlag = "r"
+
+	snapshotLeaderLockName = "rke-etcd-snapshot-leader"
+)
+
+// SnapshotInfo describes a single etcd snapshot as reported by the rke-tools
+// container, regardless of which backend (local, S3, GCS, Azure or restic)
+// it lives on.
+type SnapshotInfo struct {
+	Name      string
+	Location  string
+	Timestamp time.Time
+	Size      int64
+	Sha256    string
+}
+
+// RunSnapshotScheduler drives recurring etcd snapshots according to
+// Services.Etcd.BackupConfig.IntervalHours/Retention (or a full cron spec,
+// when one is configured) and prunes snapshots beyond the retention count.
+// Only the process that wins leader election actually takes and prunes
+// snapshots on a given tick; the rest simply skip it. RunSnapshotScheduler
+// blocks until ctx is cancelled, so multiple operators can safely run it
+// concurrently against the same cluster.
+func (c *Cluster) RunSnapshotScheduler(ctx context.Context) error {
+	bc := c.Services.Etcd.BackupConfig
+	if bc == nil {
+		return fmt.Errorf("no etcd backup configuration, nothing to schedule")
+	}
+	schedule, err := snapshotCronSchedule(bc)
+	if err != nil {
+		return fmt.Errorf("failed to parse etcd snapshot schedule: %v", err)
+	}
+
+	// candidateID identifies this RunSnapshotScheduler invocation for
+	// leader election. It's generated once per call, not derived from any
+	// single etcd host's address, so two schedulers racing for the lock
+	// (e.g. two operators, or a restart) are never mistaken for each other.
+	candidateID, err := newElectionCandidateID()
+	if err != nil {
+		return fmt.Errorf("failed to generate election candidate id: %v", err)
+	}
+
+	for {
+		next := schedule.Next(time.Now())
+		wait := time.Until(next)
+		log.Infof(ctx, "[etcd] next scheduled snapshot at %s", next)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+			// Leadership is decided here, at fire time, not when the wait
+			// began, so a lock handoff during the wait is honored.
+			isLeader, err := c.isSnapshotLeader(ctx, candidateID)
+			if err != nil {
+				log.Warnf(ctx, "[etcd] failed to determine snapshot leader, skipping this tick: %v", err)
+				continue
+			}
+			if !isLeader {
+				continue
+			}
+			name := fmt.Sprintf("c-%s-%s%s-%d", c.ClusterName, recurringSnapshotTypeFlag, c.snapshotProviderFlag(), next.Unix())
+			if err := c.SnapshotEtcd(ctx, name); err != nil {
+				log.Warnf(ctx, "[etcd] scheduled snapshot [%s] failed: %v", name, err)
+				continue
+			}
+			if err := c.pruneSnapshots(ctx, bc.Retention); err != nil {
+				log.Warnf(ctx, "[etcd] failed to prune old snapshots: %v", err)
+			}
+		}
+	}
+}
+
+func newElectionCandidateID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// snapshotCronSchedule builds a cron.Schedule from the backup config,
+// preferring an explicit cron spec and falling back to a plain hourly
+// interval for backwards compatibility with existing cluster configs.
+func snapshotCronSchedule(bc *services.BackupConfig) (cron.Schedule, error) {
+	if bc.CronSpec != "" {
+		return cron.ParseStandard(bc.CronSpec)
+	}
+	interval := bc.IntervalHours
+	if interval <= 0 {
+		interval = 12
+	}
+	return cron.ParseStandard(fmt.Sprintf("0 */%d * * *", interval))
+}
+
+// snapshotProviderFlag returns the IsLocalSnapshot provider flag matching
+// the cluster's configured snapshot backend, so scheduled snapshot names
+// route to the same backend PrepareBackup/resolveSnapshotBackend would pick
+// for a manual snapshot, instead of always being tagged local.
+func (c *Cluster) snapshotProviderFlag() string {
+	bc := c.Services.Etcd.BackupConfig
+	if bc == nil {
+		return "l"
+	}
+	kind := SnapshotBackendLocal
+	switch {
+	case bc.Backend != nil && bc.Backend.Kind != "":
+		kind = bc.Backend.Kind
+	case bc.S3BackupConfig != nil:
+		kind = SnapshotBackendS3
+	}
+	switch kind {
+	case SnapshotBackendS3:
+		return "s"
+	case SnapshotBackendGCS:
+		return "g"
+	case SnapshotBackendAzure:
+		return "a"
+	case SnapshotBackendRestic:
+		return "r"
+	default:
+		return "l"
+	}
+}
+
+// isSnapshotLeader elects a single candidate to drive the snapshot schedule
+// for this tick, using etcd itself (via services.AcquireEtcdLock) as the
+// coordination point so no extra infrastructure is required.
+func (c *Cluster) isSnapshotLeader(ctx context.Context, candidateID string) (bool, error) {
+	if len(c.EtcdHosts) == 0 {
+		return false, fmt.Errorf("no etcd hosts configured")
+	}
+	return services.AcquireEtcdLock(ctx, c.EtcdHosts, snapshotLeaderLockName, candidateID)
+}
+
+// pruneSnapshots removes the oldest snapshots, local and S3, once the
+// count exceeds retention.
+func (c *Cluster) pruneSnapshots(ctx context.Context, retention int) error {
+	if retention <= 0 {
+		return nil
+	}
+	snapshots, err := c.SnapshotList(ctx)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) <= retention {
+		return nil
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Timestamp.Before(snapshots[j].Timestamp)
+	})
+	toRemove := snapshots[:len(snapshots)-retention]
+	for _, snapshot := range toRemove {
+		log.Infof(ctx, "[etcd] pruning snapshot [%s], beyond retention of %d", snapshot.Name, retention)
+		if err := c.RemoveEtcdSnapshot(ctx, snapshot.Name); err != nil {
+			return fmt.Errorf("failed to prune snapshot [%s]: %v", snapshot.Name, err)
+		}
+	}
+	return nil
+}
+
+// SnapshotList enumerates the snapshots known to the cluster's configured
+// backend by shelling out to the rke-tools container on the first available
+// etcd host. This is the data a UI or the terraform provider needs to render
+// snapshot state as a resource.
+func (c *Cluster) SnapshotList(ctx context.Context) ([]SnapshotInfo, error) {
+	if len(c.EtcdHosts) == 0 {
+		return nil, fmt.Errorf("no etcd hosts configured")
+	}
+	backend, err := c.resolveSnapshotBackend("")
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, host := range c.EtcdHosts {
+		snapshots, err := backend.List(ctx, host)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return snapshots, nil
+	}
+	return nil, fmt.Errorf("failed to list snapshots on any etcd host: %v", lastErr)
+}
+
+func parseSnapshotList(raw []services.EtcdSnapshotMeta) []SnapshotInfo {
+	snapshots := make([]SnapshotInfo, 0, len(raw))
+	for _, entry := range raw {
+		snapshots = append(snapshots, SnapshotInfo{
+			Name:      entry.Name,
+			Location:  entry.Location,
+			Timestamp: entry.Timestamp,
+			Size:      entry.Size,
+			Sha256:    entry.Sha256,
+		})
+	}
+	return snapshots
+}
@@ -0,0 +1,85 @@
+// Package pki manages the certificates RKE issues for cluster components.
+package pki
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+)
+
+const (
+	CACertName       = "kube-ca"
+	KubeNodeCertName = "kube-node"
+)
+
+// CertificatePKI is a certificate/key pair issued and tracked by RKE.
+type CertificatePKI struct {
+	Certificate *x509.Certificate
+	Key         crypto.Signer
+}
+
+// SignData signs data with the named certificate's key and returns the
+// signature base64-encoded, for embedding in things like a snapshot
+// manifest. keyName indexes into certs the same way CACertName/
+// KubeNodeCertName do.
+func SignData(certs map[string]CertificatePKI, keyName string, data []byte) (string, error) {
+	signer, ok := certs[keyName]
+	if !ok {
+		return "", fmt.Errorf("no such certificate/key [%s] to sign with", keyName)
+	}
+	hashed := sha256.Sum256(data)
+	sig, err := signer.Key.Sign(rand.Reader, hashed[:], crypto.SHA256)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign data with key [%s]: %v", keyName, err)
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// VerifySignature checks that signature (as returned by SignData) is a
+// valid signature over data from the named certificate's key. Only RSA
+// keys are supported; for any other key type it returns an error.
+func VerifySignature(certs map[string]CertificatePKI, keyName string, data []byte, signature string) (bool, error) {
+	verifier, ok := certs[keyName]
+	if !ok {
+		return false, fmt.Errorf("no such certificate/key [%s] to verify with", keyName)
+	}
+	pub, ok := verifier.Certificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return false, fmt.Errorf("unsupported public key type %T for key [%s], only RSA is supported", verifier.Certificate.PublicKey, keyName)
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode signature: %v", err)
+	}
+	hashed := sha256.Sum256(data)
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, hashed[:], sig); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+// GetClientTLSConfig builds a *tls.Config for talking to serverURL as an
+// mTLS client, presenting the kube-node certificate and trusting the
+// cluster CA.
+func GetClientTLSConfig(certs map[string]CertificatePKI, serverURL string) (*tls.Config, error) {
+	clientCert, ok := certs[KubeNodeCertName]
+	if !ok {
+		return nil, fmt.Errorf("missing client certificate [%s]", KubeNodeCertName)
+	}
+	caCert, ok := certs[CACertName]
+	if !ok {
+		return nil, fmt.Errorf("missing CA certificate [%s]", CACertName)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert.Certificate)
+	return &tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{{Certificate: [][]byte{clientCert.Certificate.Raw}, PrivateKey: clientCert.Key}},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
@@ -0,0 +1,212 @@
+// Package services wraps the docker/ssh calls that drive RKE's system
+// service containers (etcd, control plane, etc.) on each host.
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/rke/hosts"
+)
+
+// ErrSnapshotManifestNotFound is returned by FetchEtcdSnapshotManifest when
+// a snapshot has no manifest sidecar, which is expected for snapshots taken
+// before manifests existed. Callers should treat it as "nothing to
+// validate", not as a reason to refuse a restore.
+var ErrSnapshotManifestNotFound = errors.New("snapshot manifest not found")
+
+const (
+	EtcdContainerName            = "etcd"
+	EtcdServeBackupContainerName = "etcd-serve-backup"
+)
+
+// PrivateRegistry is a minimal stand-in for the registry credentials
+// threaded through to docker when pulling the rke-tools/etcd images.
+type PrivateRegistry struct {
+	URL      string
+	User     string
+	Password string
+}
+
+// ETCDService is the `services.etcd` stanza of the cluster config.
+type ETCDService struct {
+	Image        string
+	BackupConfig *BackupConfig
+}
+
+// BackupConfig is the cluster-level etcd snapshot configuration.
+type BackupConfig struct {
+	IntervalHours int
+	Retention     int
+	// CronSpec, when set, overrides IntervalHours with a full cron schedule.
+	CronSpec string
+	// ManifestSigningKey names the pki key used to sign snapshot manifests.
+	// Empty means manifests are written unsigned.
+	ManifestSigningKey string
+
+	S3BackupConfig *S3BackupConfig
+	// Backend selects a GCS, Azure or restic destination instead of
+	// S3BackupConfig. Nil means S3BackupConfig (or, if that's also nil, the
+	// legacy local backend) applies instead.
+	Backend *SnapshotBackendConfig
+}
+
+// S3BackupConfig configures the S3 backend for etcd snapshots.
+type S3BackupConfig struct {
+	AccessKey  string
+	SecretKey  string
+	Endpoint   string
+	BucketName string
+	Region     string
+	Folder     string
+	CustomCA   string
+
+	// CredentialSource selects how AccessKey/SecretKey are resolved at
+	// snapshot/restore time instead of being read directly off this struct.
+	// See cluster.S3CredentialSource. Empty means "inline" (the fields above).
+	CredentialSource string
+	// SecretName is the kube-system Secret read when CredentialSource is
+	// "kubeSecret".
+	SecretName string
+	// ExecCommand/ExecArgs are invoked when CredentialSource is "external".
+	ExecCommand string
+	ExecArgs    []string
+
+	// S3Proxy is threaded into the rke-tools container as HTTPS_PROXY so
+	// clusters behind an egress proxy don't need it set on the host itself.
+	S3Proxy string
+}
+
+// EtcdSnapshotMeta is what the rke-tools container reports for a snapshot,
+// regardless of which backend it's stored on.
+type EtcdSnapshotMeta struct {
+	Name      string
+	Location  string
+	Timestamp time.Time
+	Size      int64
+	Sha256    string
+}
+
+// RunEtcdSnapshotSave runs the rke-tools snapshot-save verb on host. env is
+// set as the container's environment (e.g. resolved S3 credentials); it is
+// never appended to argv, where it would leak via ps/docker inspect, and is
+// never persisted on disk.
+func RunEtcdSnapshotSave(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string, self bool, etcd *ETCDService, env []string) error {
+	return runEtcdToolsCommand(ctx, host, prsMap, etcdSnapshotImage, []string{"etcd-backup", "save", "--name", name}, env)
+}
+
+// RunEtcdSnapshotRemove runs the rke-tools snapshot-remove verb on host.
+func RunEtcdSnapshotRemove(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string, self bool, etcd *ETCDService) error {
+	return runEtcdToolsCommand(ctx, host, prsMap, etcdSnapshotImage, []string{"etcd-backup", "delete", "--name", name}, nil)
+}
+
+// GetEtcdSnapshotChecksum returns the sha256 of the named snapshot as
+// reported by the rke-tools container on host.
+func GetEtcdSnapshotChecksum(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string) (string, error) {
+	return runEtcdToolsCommandOutput(ctx, host, prsMap, etcdSnapshotImage, []string{"etcd-backup", "checksum", "--name", name}, nil)
+}
+
+// StartBackupServer starts the one-shot HTTP server rke-tools uses to serve
+// a local snapshot to the other etcd hosts during restore.
+func StartBackupServer(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string) error {
+	return runEtcdToolsCommand(ctx, host, prsMap, etcdSnapshotImage, []string{"etcd-backup", "serve", "--name", name}, nil)
+}
+
+// DownloadEtcdSnapshotFromBackupServer fetches a snapshot from the backup
+// server host started by StartBackupServer.
+func DownloadEtcdSnapshotFromBackupServer(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string, backupServer *hosts.Host) error {
+	return runEtcdToolsCommand(ctx, host, prsMap, etcdSnapshotImage, []string{"etcd-backup", "download", "--name", name, "--from", backupServer.Address}, nil)
+}
+
+// DownloadEtcdSnapshotFromS3 downloads a snapshot from the configured S3
+// backend. env carries resolved credentials/proxy settings (see
+// cluster.S3CredentialSource), set as the container's environment, never
+// appended to argv.
+func DownloadEtcdSnapshotFromS3(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string, etcd *ETCDService, env []string) error {
+	return runEtcdToolsCommand(ctx, host, prsMap, etcdSnapshotImage, []string{"etcd-backup", "download-s3", "--name", name}, env)
+}
+
+// GetEtcdInitialCluster builds the etcd --initial-cluster flag value from
+// the given etcd hosts.
+func GetEtcdInitialCluster(etcdHosts []*hosts.Host) string {
+	members := make([]string, 0, len(etcdHosts))
+	for _, host := range etcdHosts {
+		members = append(members, host.Address+"="+"https://"+host.Address+":2380")
+	}
+	initialCluster := ""
+	for i, member := range members {
+		if i > 0 {
+			initialCluster += ","
+		}
+		initialCluster += member
+	}
+	return initialCluster
+}
+
+// GetEtcdSnapshotSize returns the size, in bytes, of the named snapshot as
+// reported by the rke-tools container on host.
+func GetEtcdSnapshotSize(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string) (int64, error) {
+	out, err := runEtcdToolsCommandOutput(ctx, host, prsMap, etcdSnapshotImage, []string{"etcd-backup", "size", "--name", name}, nil)
+	if err != nil {
+		return 0, err
+	}
+	out = strings.TrimSpace(out)
+	if out == "" {
+		return 0, nil
+	}
+	size, err := strconv.ParseInt(out, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse snapshot [%s] size output [%s]: %v", name, out, err)
+	}
+	return size, nil
+}
+
+// FetchEtcdSnapshotManifest reads the JSON manifest sidecar for name from
+// host, returning ErrSnapshotManifestNotFound when the snapshot predates
+// manifests and has none.
+func FetchEtcdSnapshotManifest(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string) ([]byte, error) {
+	out, err := runEtcdToolsCommandOutput(ctx, host, prsMap, etcdSnapshotImage, []string{"etcd-backup", "read-manifest", "--name", name}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return nil, ErrSnapshotManifestNotFound
+	}
+	return []byte(out), nil
+}
+
+// WriteEtcdSnapshotManifest writes manifest (already JSON-encoded) as the
+// sidecar for name on host. manifest is base64-encoded onto the
+// write-manifest verb's --manifest flag, since rke-tools verbs are shelled
+// out as plain argv and raw JSON (quotes, newlines) wouldn't survive that
+// unescaped.
+func WriteEtcdSnapshotManifest(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string, manifest []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(manifest)
+	return runEtcdToolsCommand(ctx, host, prsMap, etcdSnapshotImage, []string{"etcd-backup", "write-manifest", "--name", name, "--manifest", encoded}, nil)
+}
+
+// RestoreEtcdSnapshot restores the named snapshot into a fresh etcd member
+// on host, using initCluster as the --initial-cluster value.
+func RestoreEtcdSnapshot(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdImage, name, initCluster string) error {
+	return runEtcdToolsCommand(ctx, host, prsMap, etcdImage, []string{"etcd-backup", "restore", "--name", name, "--initial-cluster", initCluster}, nil)
+}
+
+// runEtcdToolsCommand and runEtcdToolsCommandOutput are the shared
+// plumbing every snapshot verb above goes through. They take the rke-tools
+// CLI args and the container's environment separately (env is never
+// appended to args, so secrets never show up in ps/docker inspect output).
+// runEtcdToolsCommandOutput does not start a container: it returns empty
+// output and a nil error for every call.
+func runEtcdToolsCommand(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, image string, args, env []string) error {
+	_, err := runEtcdToolsCommandOutput(ctx, host, prsMap, image, args, env)
+	return err
+}
+
+func runEtcdToolsCommandOutput(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, image string, args, env []string) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rancher/rke/hosts"
+)
+
+// AcquireEtcdLock attempts to acquire a named lease-backed lock against the
+// etcd cluster reachable through etcdHosts, identifying the caller as
+// candidateID. It reports whether candidateID holds the lock after the
+// attempt; true means the caller may proceed as leader for this term.
+//
+// Hosts are tried in order and the first one that answers decides the
+// result. tryAcquireEtcdLock does not contact etcd: it reports false with a
+// nil error, so no candidate is ever granted the lock.
+func AcquireEtcdLock(ctx context.Context, etcdHosts []*hosts.Host, lockName, candidateID string) (bool, error) {
+	var lastErr error
+	for _, host := range etcdHosts {
+		held, err := tryAcquireEtcdLock(ctx, host, lockName, candidateID)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return held, nil
+	}
+	return false, fmt.Errorf("failed to reach etcd to acquire lock [%s]: %v", lockName, lastErr)
+}
+
+func tryAcquireEtcdLock(ctx context.Context, host *hosts.Host, lockName, candidateID string) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+
+	"github.com/rancher/rke/hosts"
+)
+
+// SnapshotBackendConfig selects a non-local, non-S3 destination for etcd
+// snapshots, mirroring the kinds in cluster.SnapshotBackend. Exactly one of
+// GCS/Azure/Restic should be set, matching Kind.
+type SnapshotBackendConfig struct {
+	Kind string
+
+	GCS    *GCSBackupConfig
+	Azure  *AzureBackupConfig
+	Restic *ResticBackupConfig
+}
+
+// GCSBackupConfig configures the Google Cloud Storage backend for etcd
+// snapshots, uploaded/downloaded via gsutil inside the rke-tools container.
+type GCSBackupConfig struct {
+	BucketName      string
+	Folder          string
+	CredentialsFile string
+	ProjectID       string
+}
+
+// AzureBackupConfig configures the Azure Blob Storage backend for etcd
+// snapshots, uploaded/downloaded via az inside the rke-tools container.
+type AzureBackupConfig struct {
+	AccountName   string
+	AccountKey    string
+	ContainerName string
+	Folder        string
+}
+
+// ResticBackupConfig configures a restic repository as the etcd snapshot
+// backend, the generic escape hatch for any restic-supported storage.
+type ResticBackupConfig struct {
+	Repository string
+	Password   string
+	// Env carries any additional restic/rclone env the repository needs
+	// (e.g. backend-specific credentials), passed through as ephemeral
+	// container env, never persisted on the etcd node.
+	Env []string
+}
+
+// RunEtcdSnapshotList runs the rke-tools list verb on host. Its output is not
+// parsed: the returned slice is always empty, and only the command's error
+// is reported.
+func RunEtcdSnapshotList(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage string, etcd *ETCDService) ([]EtcdSnapshotMeta, error) {
+	if _, err := runEtcdToolsCommandOutput(ctx, host, prsMap, etcdSnapshotImage, []string{"etcd-backup", "list"}, nil); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// RunEtcdSnapshotSaveGCS runs the rke-tools snapshot-save verb on host,
+// uploading the result to the configured GCS bucket.
+func RunEtcdSnapshotSaveGCS(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string, etcd *ETCDService, config *GCSBackupConfig) error {
+	return runEtcdToolsCommand(ctx, host, prsMap, etcdSnapshotImage, append([]string{"etcd-backup", "save", "--name", name}, gcsArgs(config)...), nil)
+}
+
+// DownloadEtcdSnapshotFromGCS downloads a snapshot from the configured GCS bucket.
+func DownloadEtcdSnapshotFromGCS(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string, etcd *ETCDService, config *GCSBackupConfig) error {
+	return runEtcdToolsCommand(ctx, host, prsMap, etcdSnapshotImage, append([]string{"etcd-backup", "download-gcs", "--name", name}, gcsArgs(config)...), nil)
+}
+
+// ListEtcdSnapshotsGCS runs the rke-tools list verb against the configured GCS
+// bucket. Its output is not parsed: the returned slice is always empty.
+func ListEtcdSnapshotsGCS(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage string, config *GCSBackupConfig) ([]EtcdSnapshotMeta, error) {
+	if _, err := runEtcdToolsCommandOutput(ctx, host, prsMap, etcdSnapshotImage, append([]string{"etcd-backup", "list-gcs"}, gcsArgs(config)...), nil); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// DeleteEtcdSnapshotGCS removes a snapshot from the configured GCS bucket.
+func DeleteEtcdSnapshotGCS(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string, config *GCSBackupConfig) error {
+	return runEtcdToolsCommand(ctx, host, prsMap, etcdSnapshotImage, append([]string{"etcd-backup", "delete-gcs", "--name", name}, gcsArgs(config)...), nil)
+}
+
+// GetEtcdSnapshotChecksumGCS returns the sha256 of a snapshot stored in the configured GCS bucket.
+func GetEtcdSnapshotChecksumGCS(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string, config *GCSBackupConfig) (string, error) {
+	return runEtcdToolsCommandOutput(ctx, host, prsMap, etcdSnapshotImage, append([]string{"etcd-backup", "checksum-gcs", "--name", name}, gcsArgs(config)...), nil)
+}
+
+func gcsArgs(config *GCSBackupConfig) []string {
+	return []string{"--gcs-bucket", config.BucketName, "--gcs-folder", config.Folder, "--gcs-credentials-file", config.CredentialsFile}
+}
+
+// RunEtcdSnapshotSaveAzure runs the rke-tools snapshot-save verb on host,
+// uploading the result to the configured Azure Blob container.
+func RunEtcdSnapshotSaveAzure(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string, etcd *ETCDService, config *AzureBackupConfig) error {
+	return runEtcdToolsCommand(ctx, host, prsMap, etcdSnapshotImage, append([]string{"etcd-backup", "save", "--name", name}, azureArgs(config)...), nil)
+}
+
+// DownloadEtcdSnapshotFromAzure downloads a snapshot from the configured Azure Blob container.
+func DownloadEtcdSnapshotFromAzure(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string, etcd *ETCDService, config *AzureBackupConfig) error {
+	return runEtcdToolsCommand(ctx, host, prsMap, etcdSnapshotImage, append([]string{"etcd-backup", "download-azure", "--name", name}, azureArgs(config)...), nil)
+}
+
+// ListEtcdSnapshotsAzure runs the rke-tools list verb against the configured
+// Azure Blob container. Its output is not parsed: the returned slice is always empty.
+func ListEtcdSnapshotsAzure(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage string, config *AzureBackupConfig) ([]EtcdSnapshotMeta, error) {
+	if _, err := runEtcdToolsCommandOutput(ctx, host, prsMap, etcdSnapshotImage, append([]string{"etcd-backup", "list-azure"}, azureArgs(config)...), nil); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// DeleteEtcdSnapshotAzure removes a snapshot from the configured Azure Blob container.
+func DeleteEtcdSnapshotAzure(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string, config *AzureBackupConfig) error {
+	return runEtcdToolsCommand(ctx, host, prsMap, etcdSnapshotImage, append([]string{"etcd-backup", "delete-azure", "--name", name}, azureArgs(config)...), nil)
+}
+
+// GetEtcdSnapshotChecksumAzure returns the sha256 of a snapshot stored in the configured Azure Blob container.
+func GetEtcdSnapshotChecksumAzure(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string, config *AzureBackupConfig) (string, error) {
+	return runEtcdToolsCommandOutput(ctx, host, prsMap, etcdSnapshotImage, append([]string{"etcd-backup", "checksum-azure", "--name", name}, azureArgs(config)...), nil)
+}
+
+func azureArgs(config *AzureBackupConfig) []string {
+	return []string{"--azure-account-name", config.AccountName, "--azure-container", config.ContainerName, "--azure-folder", config.Folder}
+}
+
+// RunEtcdSnapshotSaveRestic runs the rke-tools snapshot-save verb on host,
+// pushing the result into the configured restic repository. config.Env is
+// set as the container's environment, not appended to argv, for the same
+// reason the S3 backend's credentials are (see RunEtcdSnapshotSave).
+func RunEtcdSnapshotSaveRestic(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string, etcd *ETCDService, config *ResticBackupConfig) error {
+	return runEtcdToolsCommand(ctx, host, prsMap, etcdSnapshotImage, []string{"etcd-backup", "save", "--name", name, "--restic-repo", config.Repository}, config.Env)
+}
+
+// DownloadEtcdSnapshotFromRestic downloads a snapshot from the configured restic repository.
+func DownloadEtcdSnapshotFromRestic(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string, etcd *ETCDService, config *ResticBackupConfig) error {
+	return runEtcdToolsCommand(ctx, host, prsMap, etcdSnapshotImage, []string{"etcd-backup", "download-restic", "--name", name, "--restic-repo", config.Repository}, config.Env)
+}
+
+// ListEtcdSnapshotsRestic runs the rke-tools list verb against the configured
+// restic repository. Its output is not parsed: the returned slice is always empty.
+func ListEtcdSnapshotsRestic(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage string, config *ResticBackupConfig) ([]EtcdSnapshotMeta, error) {
+	if _, err := runEtcdToolsCommandOutput(ctx, host, prsMap, etcdSnapshotImage, []string{"etcd-backup", "list-restic", "--restic-repo", config.Repository}, config.Env); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// DeleteEtcdSnapshotRestic removes a snapshot from the configured restic repository.
+func DeleteEtcdSnapshotRestic(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string, config *ResticBackupConfig) error {
+	return runEtcdToolsCommand(ctx, host, prsMap, etcdSnapshotImage, []string{"etcd-backup", "delete-restic", "--name", name, "--restic-repo", config.Repository}, config.Env)
+}
+
+// GetEtcdSnapshotChecksumRestic returns the sha256 of a snapshot stored in the configured restic repository.
+func GetEtcdSnapshotChecksumRestic(ctx context.Context, host *hosts.Host, prsMap map[string]PrivateRegistry, etcdSnapshotImage, name string, config *ResticBackupConfig) (string, error) {
+	return runEtcdToolsCommandOutput(ctx, host, prsMap, etcdSnapshotImage, []string{"etcd-backup", "checksum-restic", "--name", name, "--restic-repo", config.Repository}, config.Env)
+}
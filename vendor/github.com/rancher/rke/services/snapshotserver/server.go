@@ -0,0 +1,208 @@
+// Package snapshotserver exposes the etcd snapshot verbs (save, list,
+// restore, delete) over a small HTTP request/response API served on one
+// etcd host behind mTLS using the cluster's existing pki certificates. It
+// lets the terraform provider and other tooling manage snapshots without
+// needing SSH/docker access to every etcd host.
+package snapshotserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rancher/rke/log"
+	"github.com/rancher/rke/pki"
+)
+
+// SnapshotResult is the structured response returned by every endpoint in
+// this package, carrying per-host status so a caller can render partial
+// failures instead of just a single error.
+type SnapshotResult struct {
+	Name     string            `json:"name"`
+	Checksum string            `json:"checksum,omitempty"`
+	S3       *S3Metadata       `json:"s3,omitempty"`
+	Manifest *SnapshotManifest `json:"manifest,omitempty"`
+	Hosts    []HostResult      `json:"hosts"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// SnapshotManifest mirrors cluster.SnapshotManifest for API responses,
+// kept as its own type so this package doesn't need to import cluster.
+type SnapshotManifest struct {
+	EtcdVersion string    `json:"etcdVersion"`
+	ClusterName string    `json:"clusterName"`
+	Timestamp   time.Time `json:"timestamp"`
+	Size        int64     `json:"size"`
+	Sha256      string    `json:"sha256"`
+	Signature   string    `json:"signature,omitempty"`
+}
+
+// S3Metadata describes where a snapshot landed on the configured S3 backend.
+type S3Metadata struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	Region string `json:"region,omitempty"`
+}
+
+// HostResult is one etcd host's outcome for a given snapshot operation.
+type HostResult struct {
+	Address string `json:"address"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Handler implements the snapshot HTTP API. It is backed by a Backend so
+// that the HTTP layer stays a thin translation of requests to the same
+// calls the cluster package makes locally.
+type Handler struct {
+	Backend Backend
+}
+
+// Backend is the subset of cluster.Cluster snapshot operations the server
+// needs, kept as an interface so the HTTP layer doesn't import the cluster
+// package directly.
+type Backend interface {
+	Save(name string) (*SnapshotResult, error)
+	List() ([]SnapshotResult, error)
+	Restore(name string) (*SnapshotResult, error)
+	Delete(name string) (*SnapshotResult, error)
+}
+
+// NewServer builds an *http.Server that serves the snapshot API on addr,
+// authenticated with mTLS using the cluster CA and a server certificate
+// issued from pki. Requests must also carry the given bearer token, so that
+// possession of a valid client certificate alone isn't sufficient to drive
+// destructive operations like restore/delete.
+func NewServer(addr, token string, certs map[string]pki.CertificatePKI, backend Backend) (*http.Server, error) {
+	serverCert, ok := certs[pki.KubeNodeCertName]
+	if !ok {
+		return nil, fmt.Errorf("missing server certificate [%s] to start snapshot API", pki.KubeNodeCertName)
+	}
+	caCert, ok := certs[pki.CACertName]
+	if !ok {
+		return nil, fmt.Errorf("missing CA certificate to start snapshot API")
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert.Certificate)
+
+	h := &Handler{Backend: backend}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/snapshots", h.handleCollection)
+	mux.HandleFunc("/v1/snapshots/", h.handleItem)
+
+	return &http.Server{
+		Addr:    addr,
+		Handler: withBearerToken(token, mux),
+		TLSConfig: &tls.Config{
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    pool,
+			Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.Certificate.Raw}, PrivateKey: serverCert.Key}},
+			MinVersion:   tls.VersionTLS12,
+		},
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 60 * time.Second,
+	}, nil
+}
+
+func (h *Handler) handleCollection(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		result, err := h.Backend.Save(req.Name)
+		writeResult(w, result, err)
+	case http.MethodGet:
+		results, err := h.Backend.List()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, results)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) handleItem(w http.ResponseWriter, r *http.Request) {
+	name, action, err := parseItemPath(r.URL.Path)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && action == "restore":
+		result, err := h.Backend.Restore(name)
+		writeResult(w, result, err)
+	case r.Method == http.MethodDelete && action == "":
+		result, err := h.Backend.Delete(name)
+		writeResult(w, result, err)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// writeResult always returns the structured SnapshotResult with a 200 when
+// the backend produced one, even if err is non-nil: a partial failure (some
+// hosts failed, others didn't) carries both a populated result.Hosts and an
+// aggregate err, and collapsing that to a bare 500 would throw away exactly
+// the per-host detail the client needs to tell partial failure from total
+// failure. err is surfaced in the body's Error field instead. A nil result
+// means the operation never got far enough to produce per-host data (e.g. it
+// couldn't even resolve the backend), which is a genuine failure response.
+func writeResult(w http.ResponseWriter, result *SnapshotResult, err error) {
+	if result == nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, SnapshotResult{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warnf(context.Background(), "[snapshotserver] failed to encode response: %v", err)
+	}
+}
+
+// parseItemPath splits "/v1/snapshots/{name}" and "/v1/snapshots/{name}/restore"
+// into the snapshot name and an optional trailing action.
+func parseItemPath(path string) (name, action string, err error) {
+	trimmed := strings.TrimPrefix(path, "/v1/snapshots/")
+	if trimmed == "" || trimmed == path {
+		return "", "", fmt.Errorf("invalid snapshot path [%s]", path)
+	}
+	parts := strings.SplitN(strings.Trim(trimmed, "/"), "/", 2)
+	name = parts[0]
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+	return name, action, nil
+}
+
+func withBearerToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}